@@ -0,0 +1,149 @@
+package oauth1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+const signatureMethodPlaintext = "PLAINTEXT"
+
+type contextKey int
+
+const clientKeyContextKey contextKey = iota
+
+// ClientKeyFromContext returns the clientKey that Middleware authenticated
+// for req's context, and whether one was present.
+func ClientKeyFromContext(ctx context.Context) (string, bool) {
+	clientKey, ok := ctx.Value(clientKeyContextKey).(string)
+	return clientKey, ok
+}
+
+// ErrorHandler reports a failed validation to the client. It is responsible
+// for writing the entire response, including the status code.
+type ErrorHandler func(w http.ResponseWriter, req *http.Request, err error)
+
+// Option configures Middleware.
+type Option func(*middlewareOptions)
+
+type middlewareOptions struct {
+	errorHandler            ErrorHandler
+	realm                   string
+	allowedRealms           map[string]bool
+	allowedSignatureMethods map[string]bool
+	allowPlaintextOverHTTP  bool
+}
+
+// WithErrorHandler overrides how Middleware reports a failed validation. The
+// default writes a 401 with a WWW-Authenticate header per RFC 5849
+// Section 3.5.1.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(o *middlewareOptions) { o.errorHandler = h }
+}
+
+// WithRealm sets the realm Middleware identifies itself with in the
+// WWW-Authenticate header of its default ErrorHandler.
+func WithRealm(realm string) Option {
+	return func(o *middlewareOptions) { o.realm = realm }
+}
+
+// WithAllowedRealms restricts the realm a request's Authorization header may
+// declare. Requests that declare a realm outside this list, or that declare
+// no realm at all (unless "" is in the list), are rejected before
+// ValidateSignature runs.
+func WithAllowedRealms(realms ...string) Option {
+	return func(o *middlewareOptions) {
+		o.allowedRealms = make(map[string]bool, len(realms))
+		for _, realm := range realms {
+			o.allowedRealms[realm] = true
+		}
+	}
+}
+
+// WithSignatureMethods restricts which oauth_signature_method values
+// Middleware accepts, rejecting anything else before ValidateSignature runs.
+func WithSignatureMethods(methods ...string) Option {
+	return func(o *middlewareOptions) {
+		o.allowedSignatureMethods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			o.allowedSignatureMethods[method] = true
+		}
+	}
+}
+
+// WithAllowPlaintextOverHTTP disables Middleware's default rejection of
+// PLAINTEXT signatures on non-TLS requests. It exists for local development
+// and tests; production providers should leave it unset.
+func WithAllowPlaintextOverHTTP() Option {
+	return func(o *middlewareOptions) { o.allowPlaintextOverHTTP = true }
+}
+
+var realmParamPattern = regexp.MustCompile(`(?i)realm="([^"]*)"`)
+
+func realmFromRequest(req *http.Request) string {
+	if match := realmParamPattern.FindStringSubmatch(req.Header.Get(authorizationHeaderParam)); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// checkRequest validates everything Middleware enforces beyond
+// ValidateSignature itself. signatureMethod is the oauth_signature_method
+// parsed out of req by newProviderRequest, which — unlike
+// req.FormValue(oauthSignatureMethodParam) — also sees parameters carried in
+// the Authorization header, the transport RFC 5849 Section 3.5.1 recommends.
+func (o *middlewareOptions) checkRequest(req *http.Request, signatureMethod string) error {
+	if signatureMethod == signatureMethodPlaintext && req.TLS == nil && !o.allowPlaintextOverHTTP {
+		return fmt.Errorf("PLAINTEXT signatures are not allowed over a non-TLS connection")
+	}
+	if o.allowedSignatureMethods != nil && !o.allowedSignatureMethods[signatureMethod] {
+		return fmt.Errorf("signature method %q is not allowed", signatureMethod)
+	}
+	if o.allowedRealms != nil && !o.allowedRealms[realmFromRequest(req)] {
+		return fmt.Errorf("realm %q is not allowed", realmFromRequest(req))
+	}
+	return nil
+}
+
+func (o *middlewareOptions) handleError(w http.ResponseWriter, req *http.Request, err error) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`OAuth realm=%q`, o.realm))
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// Middleware returns a middleware that validates every request's OAuth 1
+// signature against storage before calling next, closing the gap left by
+// ValidateSignature being a bare function every caller had to wrap by hand.
+// On success, the authenticated clientKey is injected into the request's
+// context and retrievable with ClientKeyFromContext.
+func Middleware(storage ClientStorage, opts ...Option) func(http.Handler) http.Handler {
+	cfg := &middlewareOptions{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.errorHandler == nil {
+		cfg.errorHandler = cfg.handleError
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			// Parsed the same way ValidateSignature parses req, so
+			// clientKey/signatureMethod reflect Authorization-header params
+			// too, not just the URL query and POST body FormValue sees.
+			preq, err := newProviderRequest(req)
+			if err != nil {
+				cfg.errorHandler(w, req, err)
+				return
+			}
+			if err := cfg.checkRequest(req, preq.signatureMethod); err != nil {
+				cfg.errorHandler(w, req, err)
+				return
+			}
+			if err := ValidateSignature(req.Context(), req, storage); err != nil {
+				cfg.errorHandler(w, req, err)
+				return
+			}
+			ctx := context.WithValue(req.Context(), clientKeyContextKey, preq.clientKey)
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package oauth1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimestampWindow bounds how far a request's oauth_timestamp may drift from
+// the provider's clock, and how long a NonceStore must retain nonces to
+// guarantee replay protection within that drift.
+type TimestampWindow struct {
+	// MaxSkew is the largest allowed difference between a request's
+	// timestamp and the provider's current time, in either direction.
+	MaxSkew time.Duration
+
+	// Retention is how long a nonce must be remembered before a NonceStore
+	// may forget it without risking a replay. It should be at least
+	// 2*MaxSkew, since two requests up to MaxSkew apart on either side of
+	// now could otherwise share a timestamp.
+	Retention time.Duration
+}
+
+func (w TimestampWindow) checkTimestamp(ts int64, now time.Time) error {
+	skew := now.Sub(time.Unix(ts, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > w.MaxSkew {
+		return fmt.Errorf("oauth_timestamp %d is outside the allowed %s window", ts, w.MaxSkew)
+	}
+	return nil
+}
+
+// NonceStore records which (clientKey, nonce) pairs have been used at a
+// given timestamp, so ValidateSignature can reject replayed requests per
+// Section 3.3 of RFC 5849.
+type NonceStore interface {
+	// Seen reports whether nonce has already been used by clientKey at ts,
+	// and records it as used if not. Implementations should treat
+	// (clientKey, a bucket derived from ts, nonce) as the uniqueness key,
+	// rather than ts itself, so Purge can drop whole buckets at once.
+	Seen(ctx context.Context, clientKey, nonce string, ts int64) (bool, error)
+
+	// Purge discards any state for nonces that cannot have a timestamp at
+	// or after the Unix time before. Implementations that expire entries on
+	// their own (e.g. via a TTL) may make this a no-op.
+	Purge(before int64)
+}
+
+// NonceCheckingStorage adapts a NonceStore and a TimestampWindow into a
+// ClientStorage's ValidateNonce method, so a provider gets replay protection
+// without hand-rolling storage. GetSigner is delegated to the embedded
+// ClientStorage.
+type NonceCheckingStorage struct {
+	ClientStorage
+	Store  NonceStore
+	Window TimestampWindow
+}
+
+// ValidateNonce enforces Window before consulting Store, then rejects the
+// request if Store reports the nonce has already been seen.
+func (s *NonceCheckingStorage) ValidateNonce(ctx context.Context, clientKey, nonce string, timestamp int64, req *http.Request) error {
+	if err := s.Window.checkTimestamp(timestamp, time.Now()); err != nil {
+		return err
+	}
+	seen, err := s.Store.Seen(ctx, clientKey, nonce, timestamp)
+	if err != nil {
+		return err
+	}
+	if seen {
+		return fmt.Errorf("nonce %q has already been used", nonce)
+	}
+	return nil
+}
+
+// GetTokenSecret forwards to the embedded ClientStorage's TokenStorage
+// implementation, if it has one. Embedding ClientStorage only promotes
+// ClientStorage's own methods, not TokenStorage's, so without this explicit
+// forwarding method a token-aware ClientStorage wrapped in
+// NonceCheckingStorage would silently lose three-legged support: the
+// v.(TokenStorage) assertion in ValidateSignature would fail against the
+// *NonceCheckingStorage value even though the storage it wraps implements
+// TokenStorage.
+func (s *NonceCheckingStorage) GetTokenSecret(ctx context.Context, clientKey, token string, req *http.Request) (string, error) {
+	ts, ok := s.ClientStorage.(TokenStorage)
+	if !ok {
+		return "", fmt.Errorf("token signature validation not implemented")
+	}
+	return ts.GetTokenSecret(ctx, clientKey, token, req)
+}
+
+type memoryNonceBucketKey struct {
+	clientKey string
+	bucket    int64
+}
+
+// MemoryNonceStore is a NonceStore backed by an in-memory map, suitable for
+// a provider running as a single process. Nonces are grouped into buckets by
+// clientKey and timestamp, and a background goroutine periodically purges
+// buckets older than the window so memory use stays bounded.
+type MemoryNonceStore struct {
+	window     TimestampWindow
+	bucketSize int64
+
+	mu      sync.Mutex
+	buckets map[memoryNonceBucketKey]map[string]struct{}
+
+	stop chan struct{}
+}
+
+// NewMemoryNonceStore creates a MemoryNonceStore and starts its background
+// sweeper. Call Close to stop the sweeper once the store is no longer
+// needed. It returns an error if window.Retention is not positive, or is
+// less than 2*window.MaxSkew as recommended by TimestampWindow's doc
+// comment; either one would otherwise crash the sweeper goroutine or purge
+// nonces while they could still be replayed.
+func NewMemoryNonceStore(window TimestampWindow) (*MemoryNonceStore, error) {
+	if window.Retention <= 0 {
+		return nil, fmt.Errorf("nonce: window.Retention must be positive, got %s", window.Retention)
+	}
+	if window.Retention < 2*window.MaxSkew {
+		return nil, fmt.Errorf("nonce: window.Retention (%s) must be at least 2*MaxSkew (%s)", window.Retention, 2*window.MaxSkew)
+	}
+	bucketSize := int64(window.MaxSkew.Seconds()) + 1
+	s := &MemoryNonceStore{
+		window:     window,
+		bucketSize: bucketSize,
+		buckets:    make(map[memoryNonceBucketKey]map[string]struct{}),
+		stop:       make(chan struct{}),
+	}
+	go s.sweepLoop()
+	return s, nil
+}
+
+// Seen implements NonceStore.
+func (s *MemoryNonceStore) Seen(ctx context.Context, clientKey, nonce string, ts int64) (bool, error) {
+	key := memoryNonceBucketKey{clientKey: clientKey, bucket: ts / s.bucketSize}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nonces, ok := s.buckets[key]
+	if !ok {
+		nonces = make(map[string]struct{})
+		s.buckets[key] = nonces
+	}
+	if _, used := nonces[nonce]; used {
+		return true, nil
+	}
+	nonces[nonce] = struct{}{}
+	return false, nil
+}
+
+// Purge implements NonceStore.
+func (s *MemoryNonceStore) Purge(before int64) {
+	cutoff := before / s.bucketSize
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.buckets {
+		if key.bucket < cutoff {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *MemoryNonceStore) sweepLoop() {
+	interval := s.window.Retention / 4
+	if interval <= 0 {
+		interval = s.window.Retention
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.Purge(now.Add(-s.window.Retention).Unix())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background sweeper. It is safe to call Close exactly
+// once; a MemoryNonceStore is not usable afterwards.
+func (s *MemoryNonceStore) Close() {
+	close(s.stop)
+}
+
+// RedisClient is a subset of a Redis client's API. RedisNonceStore calls
+// only SetNX; Del is not used by it. A github.com/redis/go-redis/v9
+// *redis.Client does not satisfy it directly, since its SetNX and Del return
+// *redis.BoolCmd and *redis.IntCmd rather than a result and an error.
+type RedisClient interface {
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+	Del(ctx context.Context, keys ...string) (int64, error)
+}
+
+// RedisNonceStore is a NonceStore backed by Redis, suitable for providers
+// that run more than one process. Each (clientKey, bucket, nonce) triple is
+// recorded with SETNX under a TTL equal to Window.Retention, mirroring the
+// UsedAt-timestamp pattern step-ca's authorize.go uses to track used token
+// IDs: Redis itself expires stale nonces, so Purge is a no-op.
+type RedisNonceStore struct {
+	Client RedisClient
+	Window TimestampWindow
+
+	// Prefix namespaces this store's keys within the Redis keyspace. It
+	// defaults to "oauth1:nonce:" when empty.
+	Prefix string
+}
+
+// Seen implements NonceStore.
+func (s *RedisNonceStore) Seen(ctx context.Context, clientKey, nonce string, ts int64) (bool, error) {
+	bucketSize := int64(s.Window.MaxSkew.Seconds()) + 1
+	key := fmt.Sprintf("%s%s:%d:%s", s.prefix(), clientKey, ts/bucketSize, nonce)
+	stored, err := s.Client.SetNX(ctx, key, ts, s.Window.Retention)
+	if err != nil {
+		return false, err
+	}
+	return !stored, nil
+}
+
+// Purge is a no-op: RedisNonceStore relies on the TTL passed to SETNX to
+// expire entries, so Redis itself reclaims memory for expired nonces.
+func (s *RedisNonceStore) Purge(before int64) {}
+
+func (s *RedisNonceStore) prefix() string {
+	if s.Prefix != "" {
+		return s.Prefix
+	}
+	return "oauth1:nonce:"
+}
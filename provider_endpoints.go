@@ -0,0 +1,204 @@
+package oauth1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	oauthCallbackParam          = "oauth_callback"
+	oauthVerifierParam          = "oauth_verifier"
+	oauthCallbackConfirmedParam = "oauth_callback_confirmed"
+	oauthTokenSecretParam       = "oauth_token_secret"
+
+	// callbackOutOfBand is the special oauth_callback value a client sends
+	// when it has no callback URL to redirect to, per RFC 5849 Section 2.1.
+	callbackOutOfBand = "oob"
+)
+
+// TokenProvisioner issues and exchanges the temporary and token credentials
+// used by the three-legged OAuth 1.0a dance described in RFC 5849 Section 2.
+// A ClientStorage that also implements TokenProvisioner can be driven by
+// RequestTokenHandler, AuthorizeHandler, and AccessTokenHandler instead of
+// having each endpoint hand-rolled by the caller.
+type TokenProvisioner = interface {
+	TokenStorage
+
+	// NewRequestToken mints a temporary credential (token, secret) for
+	// clientKey and records callback for later use by AuthorizeHandler, per
+	// RFC 5849 Section 2.1.
+	NewRequestToken(ctx context.Context, clientKey, callback string) (token, secret string, err error)
+
+	// Authorize marks requestToken as authorized by owner and returns the
+	// verifier that AccessTokenHandler requires to exchange it, along with
+	// the callback URL recorded by NewRequestToken, per RFC 5849 Section 2.2.
+	Authorize(ctx context.Context, requestToken, owner string) (callback, verifier string, err error)
+
+	// ExchangeToken validates requestToken and verifier, then exchanges them
+	// for a permanent access token and secret, per RFC 5849 Section 2.3.
+	ExchangeToken(ctx context.Context, clientKey, requestToken, verifier string) (token, secret string, err error)
+}
+
+// tokenAwareStorage composes a ClientStorage's client lookups with a
+// TokenStorage's token-secret lookups. ProviderEndpoints keeps Storage and
+// Tokens as separate fields so a caller can store clients and issued tokens
+// in different places; this adapter lets ValidateSignature's
+// v.(TokenStorage) type assertion succeed regardless of whether Storage
+// itself implements TokenStorage.
+type tokenAwareStorage struct {
+	ClientStorage
+	Tokens TokenStorage
+}
+
+func (s tokenAwareStorage) GetTokenSecret(ctx context.Context, clientKey, token string, req *http.Request) (string, error) {
+	return s.Tokens.GetTokenSecret(ctx, clientKey, token, req)
+}
+
+// ProviderEndpoints implements the three HTTP endpoints of the OAuth 1.0a
+// provider dance: temporary credential request, resource owner
+// authorization, and token credential request.
+type ProviderEndpoints struct {
+	Storage ClientStorage
+	Tokens  TokenProvisioner
+
+	// CallbackAllowed, if set, is consulted before a callback supplied to
+	// RequestTokenHandler is recorded. Providers that only support
+	// pre-registered callback URLs should use it to reject anything else.
+	CallbackAllowed func(clientKey, callback string) bool
+
+	// AuthenticatedOwner returns the resource owner authenticating req, or
+	// an empty string if req is not authenticated. AuthorizeHandler uses it
+	// to decide who is authorizing the request token; a provider typically
+	// implements this with its existing session/cookie authentication.
+	AuthenticatedOwner func(req *http.Request) string
+}
+
+// RequestTokenHandler serves the temporary credential request endpoint of
+// RFC 5849 Section 2.1. It validates the request's signature, then mints and
+// returns a request token and secret.
+func (p *ProviderEndpoints) RequestTokenHandler(w http.ResponseWriter, req *http.Request) {
+	// Parsed the same way ValidateSignature parses req, so oauth_callback
+	// and the clientKey used below reflect Authorization-header params too,
+	// not just whatever req.FormValue finds in the URL query and POST body.
+	preq, err := newProviderRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := ValidateSignature(req.Context(), req, p.Storage); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	callback := preq.oauthParams[oauthCallbackParam]
+	if callback == "" {
+		http.Error(w, "missing oauth_callback", http.StatusBadRequest)
+		return
+	}
+	if callback != callbackOutOfBand {
+		parsed, err := url.Parse(callback)
+		if err != nil || !parsed.IsAbs() || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			http.Error(w, "invalid oauth_callback", http.StatusBadRequest)
+			return
+		}
+	}
+	clientKey := preq.clientKey
+	if p.CallbackAllowed != nil && !p.CallbackAllowed(clientKey, callback) {
+		http.Error(w, "disallowed oauth_callback", http.StatusForbidden)
+		return
+	}
+	token, secret, err := p.Tokens.NewRequestToken(req.Context(), clientKey, callback)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeTokenResponse(w, token, secret, url.Values{oauthCallbackConfirmedParam: {"true"}})
+}
+
+// AuthorizeHandler serves the resource owner authorization endpoint of RFC
+// 5849 Section 2.2. It expects the caller to have already authenticated the
+// resource owner, e.g. with a session cookie, and exposed them via
+// p.AuthenticatedOwner; it then records the authorization and redirects back
+// to the callback recorded by RequestTokenHandler with oauth_token and
+// oauth_verifier, or reports the verifier directly for "oob" callbacks.
+func (p *ProviderEndpoints) AuthorizeHandler(w http.ResponseWriter, req *http.Request) {
+	token := req.FormValue(oauthTokenParam)
+	if token == "" {
+		http.Error(w, "missing oauth_token", http.StatusBadRequest)
+		return
+	}
+	owner := ""
+	if p.AuthenticatedOwner != nil {
+		owner = p.AuthenticatedOwner(req)
+	}
+	if owner == "" {
+		http.Error(w, "resource owner is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	callback, verifier, err := p.Tokens.Authorize(req.Context(), token, owner)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if callback == "" || callback == callbackOutOfBand {
+		fmt.Fprintf(w, "oauth_verifier=%s", verifier)
+		return
+	}
+	redirectTo, err := url.Parse(callback)
+	if err != nil {
+		http.Error(w, "invalid callback", http.StatusInternalServerError)
+		return
+	}
+	query := redirectTo.Query()
+	query.Set(oauthTokenParam, token)
+	query.Set(oauthVerifierParam, verifier)
+	redirectTo.RawQuery = query.Encode()
+	http.Redirect(w, req, redirectTo.String(), http.StatusFound)
+}
+
+// AccessTokenHandler serves the token credential request endpoint of RFC
+// 5849 Section 2.3. It validates the request's signature against the
+// request token's secret, requires oauth_verifier, and exchanges the
+// request token for a permanent access token and secret.
+func (p *ProviderEndpoints) AccessTokenHandler(w http.ResponseWriter, req *http.Request) {
+	// Parsed the same way ValidateSignature parses req, so oauth_verifier
+	// and the clientKey/requestToken used below reflect Authorization-header
+	// params too, not just whatever req.FormValue finds in the URL query and
+	// POST body.
+	preq, err := newProviderRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	storage := tokenAwareStorage{ClientStorage: p.Storage, Tokens: p.Tokens}
+	if err := ValidateSignature(req.Context(), req, storage); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	verifier := preq.oauthParams[oauthVerifierParam]
+	if verifier == "" {
+		http.Error(w, "missing oauth_verifier", http.StatusBadRequest)
+		return
+	}
+	clientKey := preq.clientKey
+	requestToken := preq.token
+	token, secret, err := p.Tokens.ExchangeToken(req.Context(), clientKey, requestToken, verifier)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	writeTokenResponse(w, token, secret, nil)
+}
+
+func writeTokenResponse(w http.ResponseWriter, token, secret string, extra url.Values) {
+	values := url.Values{
+		oauthTokenParam:       {token},
+		oauthTokenSecretParam: {secret},
+	}
+	for k, vs := range extra {
+		values[k] = vs
+	}
+	w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+	w.Write([]byte(values.Encode()))
+}
@@ -18,6 +18,7 @@ type providerRequest struct {
 	timestamp         int64
 	clientKey         string
 	nonce             string
+	token             string
 }
 
 // ClientStorage represents an OAuth 1 provider's database of clients.
@@ -50,6 +51,21 @@ type ClientStorage = interface {
 	ValidateNonce(ctx context.Context, clientKey, nonce string, timestamp int64, req *http.Request) error
 }
 
+// TokenStorage represents an OAuth 1 provider's database of issued tokens,
+// both request tokens and access tokens. It is consulted by ValidateSignature whenever a request carries an
+// oauth_token, so that a ClientStorage can opt in to the three-legged flow by
+// additionally implementing this interface. A ClientStorage that does not
+// implement TokenStorage causes token-bearing requests to be rejected.
+type TokenStorage = interface {
+	// GetTokenSecret returns the token secret associated with token, which
+	// the signing key is derived from (consumerSecret&tokenSecret for
+	// HMAC-SHA1 and PLAINTEXT; see Signer.Sign). To avoid timing attacks,
+	// GetTokenSecret should return a placeholder secret and a non-nil error
+	// if the token is invalid, rather than an empty string and error, so
+	// checkSignature's runtime does not depend on the token's validity.
+	GetTokenSecret(ctx context.Context, clientKey, token string, req *http.Request) (string, error)
+}
+
 var authorizationHeaderParamPattern = regexp.MustCompile(`^\s*([^=]+)="?(\S*?)"?\s*$`)
 
 func newProviderRequest(req *http.Request) (*providerRequest, error) {
@@ -96,6 +112,7 @@ func newProviderRequest(req *http.Request) (*providerRequest, error) {
 		timestamp:         timestamp,
 		clientKey:         allParams[oauthConsumerKeyParam],
 		nonce:             allParams[oauthNonceParam],
+		token:             allParams[oauthTokenParam],
 	}
 	return preq, nil
 }
@@ -110,20 +127,41 @@ func checkMandatoryParams(params map[string]string) error {
 	if len(missingParams) > 0 {
 		return fmt.Errorf("missing required oauth params %v", strings.Join(missingParams, ", "))
 	}
-	if _, hasAccessToken := params[oauthTokenParam]; hasAccessToken {
-		return fmt.Errorf("token signature validation not implemented")
-	}
 	return nil
 }
 
 var errSignatureMismatch = fmt.Errorf("signature mismatch")
 
-func (r providerRequest) checkSignature(signer Signer) error {
+// checkSignature verifies the request's signature. tokenSecret is the secret
+// for r.token, resolved via TokenStorage; it is the empty string for
+// two-legged requests that carry no oauth_token, which matches the signing
+// key construction consumerSecret&tokenSecret degenerating to consumerSecret
+// alone per RFC 5849 Section 3.4.2.
+//
+// For RSA-SHA1 and RSA-SHA256, signer verifies the signature instead of
+// recomputing and comparing it: recomputing the signature with a Signer is impossible
+// since the provider only has the client's public key, not a signing key,
+// so signer must also implement Verifier. The verification always runs,
+// even when signer is nil, against a dummyVerifier, so that runtime does not
+// leak whether the client was valid.
+func (r providerRequest) checkSignature(signer Signer, tokenSecret string) error {
+	base := signatureBase(r.req, r.oauthParams)
+
+	if r.signatureMethod == signatureMethodRSASHA1 || r.signatureMethod == signatureMethodRSASHA256 {
+		verifier, ok := signer.(Verifier)
+		if !ok {
+			verifier = dummyVerifier{}
+		}
+		if err := verifier.Verify(base, r.signatureToVerify); err != nil {
+			return errSignatureMismatch
+		}
+		return nil
+	}
+
 	if signer == nil {
 		return errSignatureMismatch
 	}
-	base := signatureBase(r.req, r.oauthParams)
-	signature, err := signer.Sign("", base)
+	signature, err := signer.Sign(tokenSecret, base)
 	if err != nil {
 		return err
 	}
@@ -146,6 +184,11 @@ func (r providerRequest) checkSignature(signer Signer) error {
 
 // ValidateSignature checks that req contains a valid OAUTH 1 signature.
 // It returns nil if the signature is valid, or an error if the validation fails.
+//
+// If req carries an oauth_token, v must also implement TokenStorage for the
+// request to validate; this supports the three-legged flow of RFC 5849
+// Section 2 on top of the two-legged flow GetSigner/ValidateNonce provide on
+// their own.
 func ValidateSignature(ctx context.Context, req *http.Request, v ClientStorage) error {
 	preq, err := newProviderRequest(req)
 	if err != nil {
@@ -156,10 +199,24 @@ func ValidateSignature(ctx context.Context, req *http.Request, v ClientStorage)
 	}
 	signer, invalidClient := v.GetSigner(ctx, preq.clientKey, preq.signatureMethod, req)
 
-	// Check signature even if client is invalid to prevent timing attacks.
-	invalidSignature := preq.checkSignature(signer)
+	var tokenSecret string
+	var invalidToken error
+	if preq.token != "" {
+		tokens, ok := v.(TokenStorage)
+		if !ok {
+			invalidToken = fmt.Errorf("token signature validation not implemented")
+		} else {
+			tokenSecret, invalidToken = tokens.GetTokenSecret(ctx, preq.clientKey, preq.token, req)
+		}
+	}
+
+	// Check signature even if client or token is invalid to prevent timing attacks.
+	invalidSignature := preq.checkSignature(signer, tokenSecret)
 	if invalidClient != nil {
 		return invalidClient
 	}
+	if invalidToken != nil {
+		return invalidToken
+	}
 	return invalidSignature
 }